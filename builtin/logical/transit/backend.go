@@ -0,0 +1,70 @@
+package transit
+
+import (
+	"crypto/rsa"
+	"sync"
+
+	"github.com/hashicorp/vault/helper/keysutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+type backend struct {
+	*framework.Backend
+
+	lm *keysutil.LockManager
+
+	// wrappingKey and wrappingKeyLock cache the mount's RSA wrapping key
+	// used to protect key material sent to the "import/" endpoint; see
+	// getWrappingKey in path_wrapping_key.go.
+	wrappingKeyLock sync.Mutex
+	wrappingKey     *rsa.PrivateKey
+}
+
+// Factory returns a transit backend ready to be mounted.
+func Factory(conf *logical.BackendConfig) (logical.Backend, error) {
+	return Backend(conf).Setup(conf)
+}
+
+// Backend assembles the transit backend's paths and background tasks.
+// PeriodicFunc is set to periodicFunc (rotation.go): Vault's RollbackManager
+// calls a mounted backend's PeriodicFunc on a timer, and only on the active
+// node of a cluster, which is what lets rotateIfDue run as a single
+// per-mount background sweep rather than something every path handler has
+// to trigger itself.
+func Backend(conf *logical.BackendConfig) *backend {
+	b := &backend{
+		lm: keysutil.NewLockManager(),
+	}
+
+	b.Backend = &framework.Backend{
+		Help: backendHelp,
+
+		Paths: []*framework.Path{
+			b.pathConfigAutoRotate(),
+			b.pathRotateStatus(),
+			b.pathExportKeys(),
+			b.pathExportJWKS(),
+			b.pathImport(),
+			b.pathWrappingKey(),
+			b.pathBackup(),
+			b.pathBackupStream(),
+			b.pathRestore(),
+			b.pathRestoreInit(),
+			b.pathRestoreChunk(),
+			b.pathRestoreCommit(),
+			b.pathSignDerive(),
+		},
+
+		PeriodicFunc: b.periodicFunc,
+	}
+
+	return b
+}
+
+const backendHelp = `
+The transit backend handles encryption and signing keys as named,
+versioned policies, and performs the cryptographic operations -
+encryption, signing, rotation, import, export and backup/restore -
+that use them.
+`
@@ -0,0 +1,28 @@
+package transit
+
+import (
+	"fmt"
+
+	"github.com/hashicorp/vault/helper/keysutil"
+)
+
+// parseKeyType maps the wire name used in import/restore payloads to a
+// keysutil.KeyType; it is the inverse of keysutil.KeyType.String.
+func parseKeyType(name string) (keysutil.KeyType, error) {
+	switch name {
+	case "aes256-gcm96":
+		return keysutil.KeyType_AES256_GCM96, nil
+	case "ecdsa-p256":
+		return keysutil.KeyType_ECDSA_P256, nil
+	case "ed25519":
+		return keysutil.KeyType_ED25519, nil
+	case "rsa-2048":
+		return keysutil.KeyType_RSA2048, nil
+	case "rsa-4096":
+		return keysutil.KeyType_RSA4096, nil
+	case "bip32-secp256k1":
+		return keysutil.KeyType_BIP32_SECP256K1, nil
+	default:
+		return 0, fmt.Errorf("unknown key type %q", name)
+	}
+}
@@ -0,0 +1,138 @@
+package transit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/vault/helper/keysutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func (b *backend) pathBackup() *framework.Path {
+	return &framework.Path{
+		Pattern: "backup/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the key",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathBackupRead,
+		},
+
+		HelpSynopsis:    pathBackupHelpSyn,
+		HelpDescription: pathBackupHelpDesc,
+	}
+}
+
+func (b *backend) pathBackupRead(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	p, lock, err := b.lm.GetPolicyShared(req.Storage, name)
+	if lock != nil {
+		defer lock.RUnlock()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, nil
+	}
+
+	backupBytes, err := json.Marshal(keysutil.KeyData{Policy: p})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal backup: %v", err)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"backup": string(backupBytes),
+		},
+	}, nil
+}
+
+// pathBackupStream exposes a key's versions and settings like "backup/",
+// but encoded as newline-delimited JSON (one line per key version, preceded
+// by a line of policy metadata from Policy.Map, which has no storage_prefix
+// or archive_version) rather than a single buffered map. It's meant for
+// policies with hundreds of key versions, where "restore-commit" can decode
+// the stream one version at a time instead of holding the whole backup in
+// memory at once.
+func (b *backend) pathBackupStream() *framework.Path {
+	return &framework.Path{
+		Pattern: "backup-stream/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the key",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathBackupStreamRead,
+		},
+
+		HelpSynopsis:    pathBackupStreamHelpSyn,
+		HelpDescription: pathBackupStreamHelpDesc,
+	}
+}
+
+func (b *backend) pathBackupStreamRead(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	p, lock, err := b.lm.GetPolicyShared(req.Storage, name)
+	if lock != nil {
+		defer lock.RUnlock()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+
+	meta, err := p.Map(nil, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create policy map: %v", err)
+	}
+	if err := enc.Encode(meta); err != nil {
+		return nil, err
+	}
+
+	for version, key := range p.Keys {
+		if err := enc.Encode(map[string]interface{}{
+			"version": version,
+			"key":     key,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"backup": buf.String(),
+		},
+	}, nil
+}
+
+const pathBackupHelpSyn = `Backup the named key`
+const pathBackupHelpDesc = `This path is used to backup the named key.`
+
+const pathBackupStreamHelpSyn = `Backup the named key as a newline-delimited JSON stream`
+const pathBackupStreamHelpDesc = `
+This path returns a key's versions and settings like "backup/", but as
+newline-delimited JSON: a first line of policy metadata (without
+storage_prefix or archive_version) followed by one line per key
+version. Use it for keys with enough versions (RSA-4096 especially) that
+buffering the whole backup as one JSON document is expensive; pair it
+with "restore-init"/"restore-chunk"/"restore-commit" on the way back in.
+`
@@ -0,0 +1,119 @@
+package transit
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func (b *backend) pathConfigAutoRotate() *framework.Path {
+	return &framework.Path{
+		Pattern: "keys/" + framework.GenericNameRegex("name") + "/config/auto_rotate",
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the key",
+			},
+			"auto_rotate_period": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Default:     0,
+				Description: "How often the key should be rotated automatically. Set to 0 to disable automatic rotation.",
+			},
+			"auto_rotate_jitter": &framework.FieldSchema{
+				Type:        framework.TypeDurationSecond,
+				Default:     0,
+				Description: "Up to this much random slack is added to auto_rotate_period on each rotation, to avoid many keys rotating at once.",
+			},
+			"min_encryption_version_lag": &framework.FieldSchema{
+				Type:        framework.TypeInt,
+				Default:     0,
+				Description: "After each automatic rotation, min_encryption_version is advanced to latest_version minus this value. 0 leaves min_encryption_version untouched.",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathConfigAutoRotateRead,
+			logical.UpdateOperation: b.pathConfigAutoRotateWrite,
+		},
+
+		HelpSynopsis:    pathConfigAutoRotateHelpSyn,
+		HelpDescription: pathConfigAutoRotateHelpDesc,
+	}
+}
+
+func (b *backend) pathConfigAutoRotateRead(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	p, lock, err := b.lm.GetPolicyShared(req.Storage, name)
+	if lock != nil {
+		defer lock.RUnlock()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"auto_rotate_period":         int64(p.AutoRotatePeriod / time.Second),
+			"auto_rotate_jitter":         int64(p.AutoRotateJitter / time.Second),
+			"min_encryption_version_lag": p.MinEncryptionVersionLag,
+		},
+	}, nil
+}
+
+func (b *backend) pathConfigAutoRotateWrite(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	period := time.Duration(d.Get("auto_rotate_period").(int)) * time.Second
+	jitter := time.Duration(d.Get("auto_rotate_jitter").(int)) * time.Second
+	lag := d.Get("min_encryption_version_lag").(int)
+
+	if jitter > period {
+		return logical.ErrorResponse("auto_rotate_jitter cannot be larger than auto_rotate_period"), logical.ErrInvalidRequest
+	}
+	if lag < 0 {
+		return logical.ErrorResponse("min_encryption_version_lag cannot be negative"), logical.ErrInvalidRequest
+	}
+
+	p, lock, err := b.lm.GetPolicyShared(req.Storage, name)
+	if lock != nil {
+		lock.RUnlock()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return logical.ErrorResponse(fmt.Sprintf("key %q not found", name)), logical.ErrInvalidRequest
+	}
+	if period > 0 && !p.Type.RotationSupported() {
+		return logical.ErrorResponse(fmt.Sprintf("key type %s does not support rotation; auto_rotate_period must be 0", p.Type)), logical.ErrInvalidRequest
+	}
+
+	// The key's existence was just confirmed above, so an error here is a
+	// genuine storage failure (or a concurrent deletion), not a user error;
+	// propagate it rather than reporting it as "not found".
+	if err := b.lm.SetAutoRotateConfig(req.Storage, name, period, jitter, lag); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+const pathConfigAutoRotateHelpSyn = `Configure automatic rotation for a key`
+
+const pathConfigAutoRotateHelpDesc = `
+This path configures the automatic rotation schedule applied by the
+transit backend's periodic rotation check. Set auto_rotate_period to a
+nonzero duration to have the key rotated in the background once the
+newest version is older than that period; auto_rotate_jitter spreads out
+rotations that would otherwise land in the same tick, and
+min_encryption_version_lag optionally advances min_encryption_version
+after every automatic rotation so that old versions fall out of active use.
+`
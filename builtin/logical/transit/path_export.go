@@ -1,8 +1,12 @@
 package transit
 
 import (
+	"crypto/ed25519"
 	"crypto/elliptic"
+	"crypto/x509"
 	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"strconv"
@@ -17,6 +21,7 @@ const (
 	exportTypeEncryptionKey = "encryption-key"
 	exportTypeSigningKey    = "signing-key"
 	exportTypeHMACKey       = "hmac-key"
+	exportTypePublicKey     = "public-key"
 	exportTypeAll           = "all"
 )
 
@@ -26,7 +31,7 @@ func (b *backend) pathExportKeys() *framework.Path {
 		Fields: map[string]*framework.FieldSchema{
 			"type": &framework.FieldSchema{
 				Type:        framework.TypeString,
-				Description: "Type of key to export (encryption-key, signing-key, hmac-key)",
+				Description: "Type of key to export (encryption-key, signing-key, hmac-key, public-key)",
 			},
 			"name": &framework.FieldSchema{
 				Type:        framework.TypeString,
@@ -36,6 +41,16 @@ func (b *backend) pathExportKeys() *framework.Path {
 				Type:        framework.TypeString,
 				Description: "Version of the key",
 			},
+			"format": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Default:     "",
+				Description: `Encoding format for the exported key material: "" (Vault's legacy container), "raw" (base64 of the raw key bytes; symmetric/ed25519/bip32-secp256k1 only), "der" (base64 of a PKCS#8/PKIX DER blob), "pem" (the same DER, PEM wrapped), or "jwk" (an RFC 7517 JSON Web Key, public part only).`,
+			},
+			"derivation_path": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Default:     "",
+				Description: `BIP32 derivation path (e.g. "m/44'/60'/0'/0/0"). Only valid for bip32-secp256k1 keys and a specific "version"; when set, the exported key is the child key at that path rather than the stored master key.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -52,16 +67,29 @@ func (b *backend) pathPolicyExportRead(
 	exportType := d.Get("type").(string)
 	name := d.Get("name").(string)
 	version := d.Get("version").(string)
+	format := d.Get("format").(string)
+	derivationPath := d.Get("derivation_path").(string)
+
+	if derivationPath != "" && version == "" {
+		return logical.ErrorResponse("derivation_path requires a specific key \"version\""), logical.ErrInvalidRequest
+	}
 
 	switch exportType {
 	case exportTypeEncryptionKey:
 	case exportTypeSigningKey:
 	case exportTypeHMACKey:
+	case exportTypePublicKey:
 	case exportTypeAll:
 	default:
 		return logical.ErrorResponse(fmt.Sprintf("invalid export type: %s", exportType)), logical.ErrInvalidRequest
 	}
 
+	switch format {
+	case "", "raw", "der", "pem", "jwk":
+	default:
+		return logical.ErrorResponse(fmt.Sprintf("invalid format: %s", format)), logical.ErrInvalidRequest
+	}
+
 	p, lock, err := b.lm.GetPolicyShared(req.Storage, name)
 	if lock != nil {
 		defer lock.RUnlock()
@@ -73,7 +101,16 @@ func (b *backend) pathPolicyExportRead(
 		return nil, nil
 	}
 
-	if !p.Exportable {
+	// Exporting only the public half of an asymmetric key doesn't require
+	// the policy to be marked exportable, since no private material leaves
+	// Vault. That's true of exportTypePublicKey regardless of format, and
+	// of exportTypeSigningKey/exportTypeEncryptionKey when format is "jwk"
+	// (ToJWK only ever encodes public key material). It must never apply
+	// to exportTypeAll, which returns the full policy map, versions'
+	// private key material included, irrespective of format.
+	publicMaterialOnly := exportType == exportTypePublicKey ||
+		((exportType == exportTypeSigningKey || exportType == exportTypeEncryptionKey) && format == "jwk")
+	if !publicMaterialOnly && !p.Exportable {
 		return logical.ErrorResponse("key is not exportable"), nil
 	}
 
@@ -86,15 +123,29 @@ func (b *backend) pathPolicyExportRead(
 		if !p.Type.SigningSupported() {
 			return logical.ErrorResponse("signing not supported for the key"), logical.ErrInvalidRequest
 		}
+	case exportTypePublicKey:
+		if !p.Type.SigningSupported() && !p.Type.EncryptionSupported() {
+			return logical.ErrorResponse("key type does not have a public key"), logical.ErrInvalidRequest
+		}
+		if p.Type == keysutil.KeyType_AES256_GCM96 {
+			return logical.ErrorResponse("key type does not have a public key"), logical.ErrInvalidRequest
+		}
+		if p.Type == keysutil.KeyType_BIP32_SECP256K1 {
+			return logical.ErrorResponse("bip32-secp256k1 keys have no single exportable public key"), logical.ErrInvalidRequest
+		}
+	}
+
+	if err := validateExportFormat(format, exportType, p.Type); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
 	}
 
 	switch exportType {
-	case exportTypeEncryptionKey, exportTypeSigningKey, exportTypeHMACKey:
+	case exportTypeEncryptionKey, exportTypeSigningKey, exportTypeHMACKey, exportTypePublicKey:
 		retKeys := map[string]string{}
 		switch version {
 		case "":
 			for k, v := range p.Keys {
-				exportKey, err := getExportKey(p, &v, exportType)
+				exportKey, err := getExportKey(p, &v, exportType, format, k, "")
 				if err != nil {
 					return nil, err
 				}
@@ -121,7 +172,7 @@ func (b *backend) pathPolicyExportRead(
 				return logical.ErrorResponse("version does not exist or cannot be found"), logical.ErrInvalidRequest
 			}
 
-			exportKey, err := getExportKey(p, &key, exportType)
+			exportKey, err := getExportKey(p, &key, exportType, format, versionValue, derivationPath)
 			if err != nil {
 				return nil, err
 			}
@@ -149,47 +200,227 @@ func (b *backend) pathPolicyExportRead(
 	return nil, nil
 }
 
-func getExportKey(policy *keysutil.Policy, key *keysutil.KeyEntry, exportType string) (string, error) {
+// validateExportFormat rejects format/exportType/key-type combinations that
+// don't make sense: "raw" only applies to key material that is already a
+// flat byte string (symmetric keys, HMAC keys, ed25519, bip32-secp256k1),
+// while "der"/"pem" require an ASN.1-representable asymmetric key.
+func validateExportFormat(format, exportType string, keyType keysutil.KeyType) error {
+	if format == "" {
+		return nil
+	}
+
+	if exportType == exportTypeHMACKey && format != "raw" {
+		return fmt.Errorf("format %q is not supported for hmac keys; use \"raw\"", format)
+	}
+
+	switch format {
+	case "raw":
+		switch keyType {
+		case keysutil.KeyType_RSA2048, keysutil.KeyType_RSA4096, keysutil.KeyType_ECDSA_P256:
+			return fmt.Errorf("format \"raw\" is not supported for key type %s; use \"der\" or \"pem\"", keyType)
+		}
+	case "der", "pem", "jwk":
+		if keyType == keysutil.KeyType_AES256_GCM96 {
+			return fmt.Errorf("format %q is not supported for symmetric keys; use \"raw\"", format)
+		}
+		if keyType == keysutil.KeyType_BIP32_SECP256K1 {
+			return fmt.Errorf("format %q is not supported for bip32-secp256k1 keys; use \"raw\"", format)
+		}
+	}
+
+	return nil
+}
+
+func getExportKey(policy *keysutil.Policy, key *keysutil.KeyEntry, exportType, format string, version int, derivationPath string) (string, error) {
 	if policy == nil {
 		return "", errors.New("nil policy provided")
 	}
 
+	if derivationPath != "" {
+		return encodeBIP32DerivedKey(policy, key, format, derivationPath)
+	}
+
+	if format == "jwk" {
+		jwk, err := policy.ToJWK(version, key)
+		if err != nil {
+			return "", err
+		}
+		jwkBytes, err := json.Marshal(jwk)
+		if err != nil {
+			return "", err
+		}
+		return string(jwkBytes), nil
+	}
+
 	switch exportType {
 	case exportTypeHMACKey:
-		return strings.TrimSpace(base64.StdEncoding.EncodeToString(key.HMACKey)), nil
+		return encodeRaw(key.HMACKey), nil
 
 	case exportTypeEncryptionKey:
 		switch policy.Type {
 		case keysutil.KeyType_AES256_GCM96:
-			return strings.TrimSpace(base64.StdEncoding.EncodeToString(key.Key)), nil
+			return encodeRaw(key.Key), nil
 
 		case keysutil.KeyType_RSA2048, keysutil.KeyType_RSA4096:
-			return keysutil.EncodeRSAPrivateKey(key.RSAKey), nil
+			if format == "" {
+				return keysutil.EncodeRSAPrivateKey(key.RSAKey), nil
+			}
+			return encodeAsymmetricKey(format, key.RSAKey, &key.RSAKey.PublicKey)
 		}
 
 	case exportTypeSigningKey:
 		switch policy.Type {
 		case keysutil.KeyType_ECDSA_P256:
-			ecKey, err := keysutil.KeyEntryToECPrivateKey(key, elliptic.P256())
+			if format == "" {
+				return keysutil.KeyEntryToECPrivateKey(key, elliptic.P256())
+			}
+			ecKey, err := keysutil.KeyEntryToECDSAPrivateKey(key, elliptic.P256())
+			if err != nil {
+				return "", err
+			}
+			return encodeAsymmetricKey(format, ecKey, &ecKey.PublicKey)
+
+		case keysutil.KeyType_ED25519:
+			if format == "" || format == "raw" {
+				return encodeRaw(key.Key), nil
+			}
+			priv := ed25519.PrivateKey(key.Key)
+			return encodeAsymmetricKey(format, priv, priv.Public())
+
+		case keysutil.KeyType_RSA2048, keysutil.KeyType_RSA4096:
+			if format == "" {
+				return keysutil.EncodeRSAPrivateKey(key.RSAKey), nil
+			}
+			return encodeAsymmetricKey(format, key.RSAKey, &key.RSAKey.PublicKey)
+
+		case keysutil.KeyType_BIP32_SECP256K1:
+			if format == "" || format == "raw" {
+				return encodeRaw(key.Key), nil
+			}
+			return "", fmt.Errorf("format %q is not supported for bip32-secp256k1 keys; use \"raw\" or \"derivation_path\"", format)
+		}
+
+	case exportTypePublicKey:
+		switch policy.Type {
+		case keysutil.KeyType_ECDSA_P256:
+			ecKey, err := keysutil.KeyEntryToECDSAPrivateKey(key, elliptic.P256())
 			if err != nil {
 				return "", err
 			}
-			return ecKey, nil
+			return encodePublicKey(format, &ecKey.PublicKey)
 
 		case keysutil.KeyType_ED25519:
-			return strings.TrimSpace(base64.StdEncoding.EncodeToString(key.Key)), nil
+			pub := ed25519.PrivateKey(key.Key).Public()
+			return encodePublicKey(format, pub)
 
 		case keysutil.KeyType_RSA2048, keysutil.KeyType_RSA4096:
-			return keysutil.EncodeRSAPrivateKey(key.RSAKey), nil
+			return encodePublicKey(format, &key.RSAKey.PublicKey)
 		}
 	}
 
 	return "", fmt.Errorf("unknown key type %v", policy.Type)
 }
 
+// encodeBIP32DerivedKey derives the child key at derivationPath from a
+// bip32-secp256k1 master key and chain code, and returns it in the
+// requested format. Go's crypto/x509 has no OID for secp256k1, so der/pem/
+// jwk can't represent the result; only "raw" is supported.
+func encodeBIP32DerivedKey(policy *keysutil.Policy, key *keysutil.KeyEntry, format, derivationPath string) (string, error) {
+	if policy.Type != keysutil.KeyType_BIP32_SECP256K1 {
+		return "", fmt.Errorf("derivation_path is only supported for bip32-secp256k1 keys")
+	}
+
+	indexes, err := keysutil.ParseDerivationPath(derivationPath)
+	if err != nil {
+		return "", err
+	}
+
+	childKey, _, err := keysutil.DeriveChildKeyPath(key.Key, key.ChainCode, indexes)
+	if err != nil {
+		return "", err
+	}
+
+	switch format {
+	case "", "raw":
+		return encodeRaw(childKey), nil
+	default:
+		return "", fmt.Errorf("format %q is not supported for bip32-secp256k1 derived keys; use \"raw\"", format)
+	}
+}
+
+// encodeRaw base64-encodes a flat symmetric/ed25519 key. It's the "raw" and
+// legacy "" representation for key material that has no ASN.1 structure.
+func encodeRaw(b []byte) string {
+	return strings.TrimSpace(base64.StdEncoding.EncodeToString(b))
+}
+
+// encodeAsymmetricKey renders a private key as "der" (base64 of a PKCS#8
+// PrivateKeyInfo) or "pem" (the same DER, PEM wrapped).
+func encodeAsymmetricKey(format string, priv, pub interface{}) (string, error) {
+	derBytes, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal private key: %v", err)
+	}
+
+	switch format {
+	case "der":
+		return base64.StdEncoding.EncodeToString(derBytes), nil
+	case "pem":
+		block := &pem.Block{
+			Type:  "PRIVATE KEY",
+			Bytes: derBytes,
+		}
+		return string(pem.EncodeToMemory(block)), nil
+	}
+
+	return "", fmt.Errorf("unsupported format %q for private key export", format)
+}
+
+// encodePublicKey renders the public half of an asymmetric key as "der"
+// (base64 of a PKIX SubjectPublicKeyInfo) or "pem" (the same DER, PEM
+// wrapped). An empty format defaults to "pem" since there's no legacy
+// public-key-only export to stay compatible with.
+func encodePublicKey(format string, pub interface{}) (string, error) {
+	if format == "" {
+		format = "pem"
+	}
+
+	derBytes, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal public key: %v", err)
+	}
+
+	switch format {
+	case "der":
+		return base64.StdEncoding.EncodeToString(derBytes), nil
+	case "pem":
+		block := &pem.Block{
+			Type:  "PUBLIC KEY",
+			Bytes: derBytes,
+		}
+		return string(pem.EncodeToMemory(block)), nil
+	}
+
+	return "", fmt.Errorf("unsupported format %q for public key export", format)
+}
+
 const pathExportHelpSyn = `Export named encryption or signing key`
 
 const pathExportHelpDesc = `
 This path is used to export the named keys that are configured as
 exportable.
+
+The "format" parameter controls how the returned key material is encoded:
+unset uses Vault's legacy per-type container, "raw" returns the base64 of
+the flat key bytes (symmetric, ed25519 and bip32-secp256k1 keys only),
+"der"/"pem" return a standard PKCS#8 PrivateKeyInfo (or, for the
+"public-key" export type, a PKIX SubjectPublicKeyInfo) so the result can be
+consumed directly by crypto/x509-based tooling, and "jwk" returns an RFC
+7517 JSON Web Key containing only the public half of the key. See
+"export/jwks/<name>" for the equivalent JWK Set across every version of a
+key.
+
+The "public-key" export type, and the "encryption-key"/"signing-key" types
+with format "jwk", return only public key material and do not require the
+key to be configured as exportable.
 `
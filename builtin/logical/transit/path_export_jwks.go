@@ -0,0 +1,86 @@
+package transit
+
+import (
+	"sort"
+
+	"github.com/hashicorp/vault/helper/keysutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func (b *backend) pathExportJWKS() *framework.Path {
+	return &framework.Path{
+		Pattern: "export/jwks/" + framework.GenericNameRegex("name"),
+		Fields: map[string]*framework.FieldSchema{
+			"name": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Name of the key",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathExportJWKSRead,
+		},
+
+		HelpSynopsis:    pathExportJWKSHelpSyn,
+		HelpDescription: pathExportJWKSHelpDesc,
+	}
+}
+
+func (b *backend) pathExportJWKSRead(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	p, lock, err := b.lm.GetPolicyShared(req.Storage, name)
+	if lock != nil {
+		defer lock.RUnlock()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, nil
+	}
+
+	if !p.Type.SigningSupported() && !p.Type.EncryptionSupported() {
+		return logical.ErrorResponse("key type does not have a public key"), logical.ErrInvalidRequest
+	}
+	if p.Type == keysutil.KeyType_AES256_GCM96 {
+		return logical.ErrorResponse("key type does not have a public key"), logical.ErrInvalidRequest
+	}
+
+	versions := make([]int, 0, len(p.Keys))
+	for version := range p.Keys {
+		if version < p.MinDecryptionVersion {
+			continue
+		}
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+
+	jwks := make([]*keysutil.JSONWebKey, 0, len(versions))
+	for _, version := range versions {
+		key := p.Keys[version]
+		jwk, err := p.ToJWK(version, &key)
+		if err != nil {
+			return nil, err
+		}
+		jwks = append(jwks, jwk)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"keys": jwks,
+		},
+	}, nil
+}
+
+const pathExportJWKSHelpSyn = `Export a transit asymmetric key as a JSON Web Key Set`
+
+const pathExportJWKSHelpDesc = `
+This path returns every key version at or above min_decryption_version as
+an RFC 7517 JSON Web Key Set, suitable for serving directly to OIDC
+verifiers or JWT libraries that consume a JWKS document. Only the public
+half of each key is included, so this does not require exportable=true,
+matching how OIDC providers publish their signing keys.
+`
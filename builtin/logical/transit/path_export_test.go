@@ -0,0 +1,96 @@
+package transit
+
+import (
+	"testing"
+
+	"github.com/hashicorp/vault/helper/keysutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// createExportTestPolicy builds an exportable policy of the given key type,
+// rotates in one key version, and persists it so pathPolicyExportRead can
+// load it back through the backend's lock manager.
+func createExportTestPolicy(t *testing.T, storage logical.Storage, name string, keyType keysutil.KeyType) {
+	t.Helper()
+
+	p := &keysutil.Policy{
+		Name:       name,
+		Type:       keyType,
+		Exportable: true,
+	}
+	if _, err := p.Rotate(storage); err != nil {
+		t.Fatalf("failed to rotate test policy %q: %v", name, err)
+	}
+}
+
+func exportTestFieldData(exportType, name, version, format string) *framework.FieldData {
+	b := &backend{}
+	return &framework.FieldData{
+		Raw: map[string]interface{}{
+			"type":    exportType,
+			"name":    name,
+			"version": version,
+			"format":  format,
+		},
+		Schema: b.pathExportKeys().Fields,
+	}
+}
+
+func TestPathExport_RoundTrip(t *testing.T) {
+	cases := []struct {
+		name       string
+		keyType    keysutil.KeyType
+		exportType string
+	}{
+		{"aes256", keysutil.KeyType_AES256_GCM96, exportTypeEncryptionKey},
+		{"rsa2048", keysutil.KeyType_RSA2048, exportTypeSigningKey},
+		{"ecdsap256", keysutil.KeyType_ECDSA_P256, exportTypeSigningKey},
+		{"ed25519", keysutil.KeyType_ED25519, exportTypeSigningKey},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := &backend{lm: keysutil.NewLockManager()}
+			storage := &logical.InmemStorage{}
+
+			createExportTestPolicy(t, storage, tc.name, tc.keyType)
+
+			req := &logical.Request{Storage: storage}
+			resp, err := b.pathPolicyExportRead(req, exportTestFieldData(tc.exportType, tc.name, "", ""))
+			if err != nil {
+				t.Fatalf("export failed: %v", err)
+			}
+			if resp == nil || resp.IsError() {
+				t.Fatalf("unexpected error response: %#v", resp)
+			}
+
+			keys, ok := resp.Data["keys"].(map[string]string)
+			if !ok || len(keys) != 1 {
+				t.Fatalf("expected exactly one exported key version, got %#v", resp.Data["keys"])
+			}
+			if keys["1"] == "" {
+				t.Fatalf("expected non-empty key material for version 1")
+			}
+		})
+	}
+}
+
+func TestPathExport_NotExportable(t *testing.T) {
+	b := &backend{lm: keysutil.NewLockManager()}
+	storage := &logical.InmemStorage{}
+
+	p := &keysutil.Policy{Name: "sealed", Type: keysutil.KeyType_AES256_GCM96}
+	if _, err := p.Rotate(storage); err != nil {
+		t.Fatalf("failed to rotate test policy: %v", err)
+	}
+
+	req := &logical.Request{Storage: storage}
+	resp, err := b.pathPolicyExportRead(req, exportTestFieldData(exportTypeEncryptionKey, "sealed", "", ""))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error response for a non-exportable key, got %#v", resp)
+	}
+}
@@ -1,11 +1,19 @@
 package transit
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"math/big"
+	"strconv"
+	"time"
 
-	"github.com/hashicorp/vault/helper/jsonutil"
 	"github.com/hashicorp/vault/helper/keysutil"
 	"github.com/hashicorp/vault/logical"
 	"github.com/hashicorp/vault/logical/framework"
@@ -15,14 +23,20 @@ func (b *backend) pathImport() *framework.Path {
 	return &framework.Path{
 		Pattern: "import/" + framework.GenericNameRegex("name"),
 		Fields: map[string]*framework.FieldSchema{
-			"data": &framework.FieldSchema{
-				Type:        framework.TypeString,
-				Description: "Base64 encoded data to be imported. The data should be the output of the 'export/' endpoint",
-			},
 			"name": &framework.FieldSchema{
 				Type:        framework.TypeString,
 				Description: "Name of the key",
 			},
+			"ciphertext": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Base64 encoded wrapped key import blob. This should be built by ` +
+					`RSA-OAEP wrapping a fresh AES-256 key with the public key from ` +
+					`'wrapping_key', then AES-256-GCM sealing the key material (a ` +
+					`JSON object with the key "type", its settings and a "keys" ` +
+					`map of version to base64 key material) under that AES key, and ` +
+					`concatenating the RSA ciphertext, the 12-byte GCM nonce and the ` +
+					`GCM ciphertext, in that order.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -34,9 +48,50 @@ func (b *backend) pathImport() *framework.Path {
 	}
 }
 
+// importKeyVersion is the wire shape of a single key version inside an
+// import ciphertext's plaintext payload. Every key material field is a
+// string so that raw and big-integer key material can travel as base64
+// inside JSON; creation_time is an RFC 3339 timestamp.
+type importKeyVersion struct {
+	Key          string    `json:"key"`
+	HMACKey      string    `json:"hmac_key"`
+	CreationTime time.Time `json:"creation_time"`
+	RSAKey       string    `json:"rsa_key"`
+	ECX          string    `json:"ec_x"`
+	ECY          string    `json:"ec_y"`
+	ECD          string    `json:"ec_d"`
+	ChainCode    string    `json:"chain_code"`
+}
+
+// importPolicyPayload is the plaintext, once unwrapped, of an "import/"
+// ciphertext: everything needed to reconstruct a keysutil.Policy.
+type importPolicyPayload struct {
+	Type                    string                      `json:"type"`
+	Derived                 bool                        `json:"derived"`
+	ConvergentEncryption    bool                        `json:"convergent_encryption"`
+	KDF                     int                         `json:"kdf"`
+	Exportable              bool                        `json:"exportable"`
+	DeletionAllowed         bool                        `json:"deletion_allowed"`
+	MinDecryptionVersion    int                         `json:"min_decryption_version"`
+	MinEncryptionVersion    int                         `json:"min_encryption_version"`
+	LatestVersion           int                         `json:"latest_version"`
+	AutoRotatePeriodSeconds int                         `json:"auto_rotate_period_seconds"`
+	AutoRotateJitterSeconds int                         `json:"auto_rotate_jitter_seconds"`
+	MinEncryptionVersionLag int                         `json:"min_encryption_version_lag"`
+	StoragePrefix           string                      `json:"storage_prefix"`
+	Keys                    map[string]importKeyVersion `json:"keys"`
+
+	// Mnemonic, when set on a "bip32-secp256k1" import, lets the caller hand
+	// over a BIP39 mnemonic directly instead of deriving and wrapping the
+	// master key and chain code client-side; Vault derives the seed via
+	// keysutil.DeriveBIP39Seed and the master key via
+	// keysutil.MasterKeyFromSeed itself.
+	Mnemonic   string `json:"mnemonic"`
+	Passphrase string `json:"passphrase"`
+}
+
 func (b *backend) pathPolicyImportUpdate(
 	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	// Get the name of the key
 	name := d.Get("name").(string)
 
 	// If a policy already exists by the given name, error out. This avoids
@@ -44,7 +99,7 @@ func (b *backend) pathPolicyImportUpdate(
 	// it would need an explicit deletion before perfoming an import operation.
 	p, lock, err := b.lm.GetPolicyShared(req.Storage, name)
 	if lock != nil {
-		defer lock.RUnlock()
+		lock.RUnlock()
 	}
 	if err != nil {
 		return nil, err
@@ -53,110 +108,216 @@ func (b *backend) pathPolicyImportUpdate(
 		return logical.ErrorResponse(fmt.Sprintf("key %q already exists", name)), nil
 	}
 
-	// Get the base64 encoded payload
-	payloadB64 := d.Get("data").(string)
+	ciphertextB64 := d.Get("ciphertext").(string)
+	if ciphertextB64 == "" {
+		return logical.ErrorResponse("'ciphertext' is required"), logical.ErrInvalidRequest
+	}
 
-	// Base64 decode the payload
-	payloadBytes, err := base64.StdEncoding.DecodeString(payloadB64)
+	blob, err := base64.StdEncoding.DecodeString(ciphertextB64)
 	if err != nil {
-		return logical.ErrorResponse(fmt.Sprintf("unable to base64 decode 'data': %q", err)), nil
+		return logical.ErrorResponse(fmt.Sprintf("unable to base64 decode 'ciphertext': %s", err)), logical.ErrInvalidRequest
 	}
 
-	// Parse the payload into a map
-	var payloadMap map[string]interface{}
-	if err := jsonutil.DecodeJSON(payloadBytes, &payloadMap); err != nil {
+	wrappingKey, err := b.getWrappingKey(req.Storage)
+	if err != nil {
 		return nil, err
 	}
+	if wrappingKey == nil {
+		return nil, fmt.Errorf("could not load transit wrapping key")
+	}
 
-	// For testing only.
-	// TODO: Remove this
-	marshaledPayloadMap, err := json.MarshalIndent(payloadMap, "", "  ")
+	payload, err := unwrapImportBlob(wrappingKey, blob)
 	if err != nil {
-		return nil, err
+		return logical.ErrorResponse(fmt.Sprintf("unable to unwrap import ciphertext: %s", err)), logical.ErrInvalidRequest
+	}
+
+	var importPayload importPolicyPayload
+	if err := json.Unmarshal(payload, &importPayload); err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("unable to decode import payload: %s", err)), logical.ErrInvalidRequest
 	}
-	fmt.Print(string(marshaledPayloadMap))
 
-	keyTypeRaw, ok := payloadMap["type"]
-	if !ok {
-		return logical.ErrorResponse(fmt.Sprintf("missing %q in data", "type")), nil
+	keyType, err := parseKeyType(importPayload.Type)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
 	}
 
-	keyType := keyTypeRaw.(string)
-	var utilKeyType keysutil.KeyType
+	if keyType == keysutil.KeyType_BIP32_SECP256K1 && importPayload.Mnemonic != "" {
+		seed := keysutil.DeriveBIP39Seed(importPayload.Mnemonic, importPayload.Passphrase)
+		masterKey, chainCode, err := keysutil.MasterKeyFromSeed(seed)
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("unable to derive master key from mnemonic: %s", err)), logical.ErrInvalidRequest
+		}
+
+		policy := &keysutil.Policy{
+			Name:                 name,
+			Type:                 keyType,
+			ConvergentEncryption: importPayload.ConvergentEncryption,
+			KDF:                  importPayload.KDF,
+			Exportable:           importPayload.Exportable,
+			DeletionAllowed:      importPayload.DeletionAllowed,
+			LatestVersion:        1,
+			StoragePrefix:        importPayload.StoragePrefix,
+			Keys: map[int]keysutil.KeyEntry{
+				1: {
+					Key:          masterKey,
+					ChainCode:    chainCode,
+					CreationTime: time.Now(),
+				},
+			},
+		}
+
+		if err := b.lm.ImportPolicy(req.Storage, name, policy); err != nil {
+			return nil, err
+		}
+
+		return nil, nil
+	}
+
+	keys := make(map[int]keysutil.KeyEntry, len(importPayload.Keys))
+	for versionStr, v := range importPayload.Keys {
+		version, err := strconv.Atoi(versionStr)
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("invalid key version %q", versionStr)), logical.ErrInvalidRequest
+		}
+
+		entry := keysutil.KeyEntry{
+			CreationTime: v.CreationTime,
+		}
+
+		if v.Key != "" {
+			entry.Key, err = base64.StdEncoding.DecodeString(v.Key)
+			if err != nil {
+				return logical.ErrorResponse(fmt.Sprintf("unable to decode key material for version %d: %s", version, err)), logical.ErrInvalidRequest
+			}
+		}
+		if v.HMACKey != "" {
+			entry.HMACKey, err = base64.StdEncoding.DecodeString(v.HMACKey)
+			if err != nil {
+				return logical.ErrorResponse(fmt.Sprintf("unable to decode hmac key for version %d: %s", version, err)), logical.ErrInvalidRequest
+			}
+		}
+		if v.RSAKey != "" {
+			rsaDER, err := base64.StdEncoding.DecodeString(v.RSAKey)
+			if err != nil {
+				return logical.ErrorResponse(fmt.Sprintf("unable to decode rsa key for version %d: %s", version, err)), logical.ErrInvalidRequest
+			}
+			rsaKey, err := x509.ParsePKCS8PrivateKey(rsaDER)
+			if err != nil {
+				return logical.ErrorResponse(fmt.Sprintf("unable to parse rsa key for version %d: %s", version, err)), logical.ErrInvalidRequest
+			}
+			rsaPrivKey, ok := rsaKey.(*rsa.PrivateKey)
+			if !ok {
+				return logical.ErrorResponse(fmt.Sprintf("imported key for version %d is not an RSA private key", version)), logical.ErrInvalidRequest
+			}
+			entry.RSAKey = rsaPrivKey
+		}
+		if v.ECX != "" || v.ECY != "" || v.ECD != "" {
+			entry.EC_X, err = decodeBigInt(v.ECX)
+			if err != nil {
+				return logical.ErrorResponse(fmt.Sprintf("unable to decode ec_x for version %d: %s", version, err)), logical.ErrInvalidRequest
+			}
+			entry.EC_Y, err = decodeBigInt(v.ECY)
+			if err != nil {
+				return logical.ErrorResponse(fmt.Sprintf("unable to decode ec_y for version %d: %s", version, err)), logical.ErrInvalidRequest
+			}
+			entry.EC_D, err = decodeBigInt(v.ECD)
+			if err != nil {
+				return logical.ErrorResponse(fmt.Sprintf("unable to decode ec_d for version %d: %s", version, err)), logical.ErrInvalidRequest
+			}
+		}
+		if v.ChainCode != "" {
+			entry.ChainCode, err = base64.StdEncoding.DecodeString(v.ChainCode)
+			if err != nil {
+				return logical.ErrorResponse(fmt.Sprintf("unable to decode chain_code for version %d: %s", version, err)), logical.ErrInvalidRequest
+			}
+		}
 
-	switch keyType {
-	case "aes256-gcm96":
-		utilKeyType = keysutil.KeyType_AES256_GCM96
-	case "ecdsa-p256":
-		utilKeyType = keysutil.KeyType_ECDSA_P256
-	case "ed25519":
-		utilKeyType = keysutil.KeyType_ED25519
-	case "rsa-2048":
-		utilKeyType = keysutil.KeyType_RSA2048
-	case "rsa-4096":
-		utilKeyType = keysutil.KeyType_RSA4096
-	default:
-		return logical.ErrorResponse(fmt.Sprintf("unknown key type %q", keyType)), logical.ErrInvalidRequest
+		keys[version] = entry
 	}
 
-	fmt.Printf("utilKeyType: %q\n", utilKeyType)
+	latestVersion := importPayload.LatestVersion
+	if latestVersion == 0 {
+		for version := range keys {
+			if version > latestVersion {
+				latestVersion = version
+			}
+		}
+	}
 
-	derivedRaw, ok := payloadMap["derived"]
-	if !ok {
-		return logical.ErrorResponse(fmt.Sprintf("missing %q in data", "derived")), nil
+	policy := &keysutil.Policy{
+		Name:                    name,
+		Type:                    keyType,
+		Derived:                 importPayload.Derived,
+		ConvergentEncryption:    importPayload.ConvergentEncryption,
+		KDF:                     importPayload.KDF,
+		Exportable:              importPayload.Exportable,
+		DeletionAllowed:         importPayload.DeletionAllowed,
+		MinDecryptionVersion:    importPayload.MinDecryptionVersion,
+		MinEncryptionVersion:    importPayload.MinEncryptionVersion,
+		LatestVersion:           latestVersion,
+		AutoRotatePeriod:        time.Duration(importPayload.AutoRotatePeriodSeconds) * time.Second,
+		AutoRotateJitter:        time.Duration(importPayload.AutoRotateJitterSeconds) * time.Second,
+		MinEncryptionVersionLag: importPayload.MinEncryptionVersionLag,
+		StoragePrefix:           importPayload.StoragePrefix,
+		Keys:                    keys,
 	}
-	derived := derivedRaw.(bool)
 
-	exportableRaw, ok := payloadMap["exportable"]
-	if !ok {
-		return logical.ErrorResponse(fmt.Sprintf("missing %q in data", "exportable")), nil
+	if err := b.lm.ImportPolicy(req.Storage, name, policy); err != nil {
+		return nil, err
 	}
-	exportable := exportableRaw.(bool)
 
-	deletionAllowedRaw, ok := payloadMap["deletion_allowed"]
-	if !ok {
-		return logical.ErrorResponse(fmt.Sprintf("missing %q in data", "deletion_allowed")), nil
+	return nil, nil
+}
+
+// unwrapImportBlob splits a "ciphertext" blob into its RSA-OAEP wrapped AES
+// key, GCM nonce and GCM ciphertext, and returns the decrypted plaintext.
+func unwrapImportBlob(wrappingKey *rsa.PrivateKey, blob []byte) ([]byte, error) {
+	keySize := wrappingKey.Size()
+	if len(blob) < keySize {
+		return nil, fmt.Errorf("ciphertext is shorter than the wrapping key size")
 	}
-	deletionAllowed := deletionAllowedRaw.(bool)
 
-	/*
-		latestVersionRaw, ok := payloadMap["latest_version"]
-		if !ok {
-			return logical.ErrorResponse(fmt.Sprintf("missing %q in data", "latest_version")), nil
-		}
-		latestVersion := latestVersionRaw.(int)
-	*/
+	wrappedAESKey := blob[:keySize]
+	rest := blob[keySize:]
 
-	minDecryptionVersionRaw, ok := payloadMap["min_decryption_version"]
-	if !ok {
-		return logical.ErrorResponse(fmt.Sprintf("missing %q in data", "min_decryption_version")), nil
+	aesKey, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, wrappingKey, wrappedAESKey, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap AES key: %s", err)
 	}
-	fmt.Printf("minDecryptionVersionRaw: %#v\n", minDecryptionVersionRaw)
-	minDecryptionVersion := minDecryptionVersionRaw.(int)
 
-	minEncryptionVersionRaw, ok := payloadMap["min_encryption_version"]
-	if !ok {
-		return logical.ErrorResponse(fmt.Sprintf("missing %q in data", "min_encryption_version")), nil
+	block, err := aes.NewCipher(aesKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
 	}
-	minEncryptionVersion := minEncryptionVersionRaw.(int)
 
-	p = &keysutil.Policy{
-		Name:            name,
-		Type:            utilKeyType,
-		Derived:         derived,
-		Exportable:      exportable,
-		DeletionAllowed: deletionAllowed,
-		//	LatestVersion:        latestVersion,
-		MinDecryptionVersion: minDecryptionVersion,
-		MinEncryptionVersion: minEncryptionVersion,
+	if len(rest) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext is shorter than the nonce size")
 	}
+	nonce := rest[:gcm.NonceSize()]
+	sealed := rest[gcm.NonceSize():]
 
-	fmt.Printf("policy: %#v\n", p)
+	return gcm.Open(nil, nonce, sealed, nil)
+}
 
-	return nil, nil
+func decodeBigInt(s string) (*big.Int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
 }
 
-const pathImportHelpSyn = `Import keys for a given key name.`
+const pathImportHelpSyn = `Import externally generated key material for a given key name.`
 
 const pathImportHelpDesc = `
-This path is used to import the exported keys under a given key name.`
+This path is used to import key material, wrapped for confidentiality
+against the mount's current 'wrapping_key', under a new key name. See the
+'wrapping_key' endpoint for the public key and the expected wrapping
+format.`
@@ -0,0 +1,151 @@
+package transit
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/hashicorp/vault/helper/keysutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+// wrapImportBlob is the inverse of unwrapImportBlob: it RSA-OAEP wraps a
+// fresh AES-256 key with the given wrapping key's public half, then
+// AES-256-GCM seals plaintext under it, exactly as the 'import/' endpoint's
+// 'ciphertext' field is documented to expect.
+func wrapImportBlob(t *testing.T, wrappingKey *rsa.PublicKey, plaintext []byte) []byte {
+	t.Helper()
+
+	aesKey := make([]byte, 32)
+	if _, err := rand.Read(aesKey); err != nil {
+		t.Fatalf("failed to generate AES key: %v", err)
+	}
+
+	wrappedAESKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, wrappingKey, aesKey, nil)
+	if err != nil {
+		t.Fatalf("failed to wrap AES key: %v", err)
+	}
+
+	block, err := aes.NewCipher(aesKey)
+	if err != nil {
+		t.Fatalf("failed to create AES cipher: %v", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatalf("failed to create GCM: %v", err)
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		t.Fatalf("failed to generate nonce: %v", err)
+	}
+	sealed := gcm.Seal(nil, nonce, plaintext, nil)
+
+	blob := append([]byte{}, wrappedAESKey...)
+	blob = append(blob, nonce...)
+	blob = append(blob, sealed...)
+	return blob
+}
+
+func importTestFieldData(name, ciphertextB64 string) *framework.FieldData {
+	b := &backend{}
+	return &framework.FieldData{
+		Raw: map[string]interface{}{
+			"name":       name,
+			"ciphertext": ciphertextB64,
+		},
+		Schema: b.pathImport().Fields,
+	}
+}
+
+func TestPathImport_RoundTrip(t *testing.T) {
+	b := &backend{lm: keysutil.NewLockManager()}
+	storage := &logical.InmemStorage{}
+
+	wrappingKey, err := b.getWrappingKey(storage)
+	if err != nil {
+		t.Fatalf("failed to get wrapping key: %v", err)
+	}
+
+	aesKey := make([]byte, 32)
+	if _, err := rand.Read(aesKey); err != nil {
+		t.Fatalf("failed to generate key material: %v", err)
+	}
+
+	payload := importPolicyPayload{
+		Type:                 "aes256-gcm96",
+		Exportable:           true,
+		ConvergentEncryption: true,
+		KDF:                  1,
+		LatestVersion:        1,
+		StoragePrefix:        "custom-prefix",
+		Keys: map[string]importKeyVersion{
+			"1": {Key: base64.StdEncoding.EncodeToString(aesKey)},
+		},
+	}
+	plaintext, err := json.Marshal(&payload)
+	if err != nil {
+		t.Fatalf("failed to marshal import payload: %v", err)
+	}
+
+	blob := wrapImportBlob(t, &wrappingKey.PublicKey, plaintext)
+	ciphertextB64 := base64.StdEncoding.EncodeToString(blob)
+
+	req := &logical.Request{Storage: storage}
+	resp, err := b.pathPolicyImportUpdate(req, importTestFieldData("imported", ciphertextB64))
+	if err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if resp != nil && resp.IsError() {
+		t.Fatalf("unexpected error response: %#v", resp)
+	}
+
+	p, lock, err := b.lm.GetPolicyShared(storage, "imported")
+	if lock != nil {
+		defer lock.RUnlock()
+	}
+	if err != nil {
+		t.Fatalf("failed to load imported policy: %v", err)
+	}
+	if p == nil {
+		t.Fatalf("expected imported policy to exist")
+	}
+
+	if !p.ConvergentEncryption {
+		t.Errorf("expected ConvergentEncryption to round-trip as true")
+	}
+	if p.KDF != 1 {
+		t.Errorf("expected KDF to round-trip as 1, got %d", p.KDF)
+	}
+	if p.StoragePrefix != "custom-prefix" {
+		t.Errorf("expected StoragePrefix to round-trip, got %q", p.StoragePrefix)
+	}
+	if key, ok := p.Keys[1]; !ok || len(key.Key) != len(aesKey) {
+		t.Errorf("expected version 1 key material to round-trip")
+	}
+}
+
+func TestPathImport_RejectsDuplicateName(t *testing.T) {
+	b := &backend{lm: keysutil.NewLockManager()}
+	storage := &logical.InmemStorage{}
+
+	existing := &keysutil.Policy{Name: "dup", Type: keysutil.KeyType_AES256_GCM96}
+	if _, err := existing.Rotate(storage); err != nil {
+		t.Fatalf("failed to set up existing policy: %v", err)
+	}
+
+	req := &logical.Request{Storage: storage}
+	resp, err := b.pathPolicyImportUpdate(req, importTestFieldData("dup", "irrelevant"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp == nil || !resp.IsError() {
+		t.Fatalf("expected an error response for a name that already exists, got %#v", resp)
+	}
+}
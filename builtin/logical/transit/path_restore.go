@@ -56,6 +56,10 @@ func (b *backend) pathRestoreUpdate(req *logical.Request, d *framework.FieldData
 		// Enable weak decode to handle conversion of string indices to integer
 		// within a map
 		WeaklyTypedInput: true,
+		// Match backup/ fields (e.g. "min_decryption_version") by their json
+		// tag rather than by Go field name, since Policy and KeyEntry are
+		// snake_case over the wire.
+		TagName: "json",
 	}
 	decoder, err := mapstructure.NewDecoder(config)
 	if err != nil {
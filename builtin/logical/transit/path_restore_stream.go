@@ -0,0 +1,323 @@
+package transit
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/hashicorp/vault/helper/keysutil"
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const restoreUploadStoragePrefix = "restore-upload/"
+
+// restoreUploadMeta tracks how many chunks have been appended to an upload,
+// so restore-commit can fetch them back in order without ever holding more
+// than one chunk in memory at a time.
+type restoreUploadMeta struct {
+	ChunkCount int `json:"chunk_count"`
+}
+
+func restoreUploadMetaKey(token string) string {
+	return restoreUploadStoragePrefix + token
+}
+
+func restoreUploadChunkKey(token string, index int) string {
+	return fmt.Sprintf("%s%s/chunks/%010d", restoreUploadStoragePrefix, token, index)
+}
+
+func (b *backend) pathRestoreInit() *framework.Path {
+	return &framework.Path{
+		Pattern: "restore-init",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathRestoreInitUpdate,
+		},
+
+		HelpSynopsis:    pathRestoreInitHelpSyn,
+		HelpDescription: pathRestoreInitHelpDesc,
+	}
+}
+
+func (b *backend) pathRestoreInitUpdate(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	tokenBytes := make([]byte, 16)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return nil, err
+	}
+	token := hex.EncodeToString(tokenBytes)
+
+	meta, err := json.Marshal(&restoreUploadMeta{})
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(&logical.StorageEntry{
+		Key:   restoreUploadMetaKey(token),
+		Value: meta,
+	}); err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"token": token,
+		},
+	}, nil
+}
+
+func (b *backend) pathRestoreChunk() *framework.Path {
+	return &framework.Path{
+		Pattern: "restore-chunk",
+		Fields: map[string]*framework.FieldSchema{
+			"token": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Upload token returned by 'restore-init'",
+			},
+			"chunk": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Base64 encoded frame of the backup-stream payload",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathRestoreChunkUpdate,
+		},
+
+		HelpSynopsis:    pathRestoreChunkHelpSyn,
+		HelpDescription: pathRestoreChunkHelpDesc,
+	}
+}
+
+func (b *backend) pathRestoreChunkUpdate(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	token := d.Get("token").(string)
+	chunkB64 := d.Get("chunk").(string)
+
+	metaEntry, err := req.Storage.Get(restoreUploadMetaKey(token))
+	if err != nil {
+		return nil, err
+	}
+	if metaEntry == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown upload token %q; call 'restore-init' first", token)), logical.ErrInvalidRequest
+	}
+	var meta restoreUploadMeta
+	if err := json.Unmarshal(metaEntry.Value, &meta); err != nil {
+		return nil, err
+	}
+
+	frame, err := base64.StdEncoding.DecodeString(chunkB64)
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("unable to base64 decode 'chunk': %s", err)), logical.ErrInvalidRequest
+	}
+
+	if err := req.Storage.Put(&logical.StorageEntry{
+		Key:   restoreUploadChunkKey(token, meta.ChunkCount),
+		Value: frame,
+	}); err != nil {
+		return nil, err
+	}
+
+	meta.ChunkCount++
+	metaBytes, err := json.Marshal(&meta)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(&logical.StorageEntry{
+		Key:   restoreUploadMetaKey(token),
+		Value: metaBytes,
+	}); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathRestoreCommit() *framework.Path {
+	return &framework.Path{
+		Pattern: "restore-commit",
+		Fields: map[string]*framework.FieldSchema{
+			"token": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: "Upload token returned by 'restore-init'",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathRestoreCommitUpdate,
+		},
+
+		HelpSynopsis:    pathRestoreCommitHelpSyn,
+		HelpDescription: pathRestoreCommitHelpDesc,
+	}
+}
+
+func (b *backend) pathRestoreCommitUpdate(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	token := d.Get("token").(string)
+
+	metaEntry, err := req.Storage.Get(restoreUploadMetaKey(token))
+	if err != nil {
+		return nil, err
+	}
+	if metaEntry == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown upload token %q", token)), logical.ErrInvalidRequest
+	}
+	var meta restoreUploadMeta
+	if err := json.Unmarshal(metaEntry.Value, &meta); err != nil {
+		return nil, err
+	}
+	defer b.deleteRestoreUpload(req.Storage, token, meta.ChunkCount)
+
+	p, err := decodeBackupStream(&restoreUploadReader{storage: req.Storage, token: token, chunkCount: meta.ChunkCount})
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("unable to decode uploaded backup: %s", err)), logical.ErrInvalidRequest
+	}
+
+	if err := b.lm.RestorePolicy(req.Storage, keysutil.KeyData{Policy: p}); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// deleteRestoreUpload removes every chunk and the meta entry belonging to a
+// completed or abandoned upload.
+func (b *backend) deleteRestoreUpload(storage logical.Storage, token string, chunkCount int) {
+	for i := 0; i < chunkCount; i++ {
+		storage.Delete(restoreUploadChunkKey(token, i))
+	}
+	storage.Delete(restoreUploadMetaKey(token))
+}
+
+// restoreUploadReader streams a chunked restore upload back out of storage
+// one chunk at a time, so restore-commit never needs the full upload
+// resident in memory at once the way a single concatenated storage entry
+// would.
+type restoreUploadReader struct {
+	storage    logical.Storage
+	token      string
+	chunkCount int
+
+	next int
+	buf  []byte
+}
+
+func (r *restoreUploadReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.next >= r.chunkCount {
+			return 0, io.EOF
+		}
+		entry, err := r.storage.Get(restoreUploadChunkKey(r.token, r.next))
+		if err != nil {
+			return 0, err
+		}
+		if entry == nil {
+			return 0, fmt.Errorf("missing chunk %d of upload", r.next)
+		}
+		r.buf = entry.Value
+		r.next++
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// decodeBackupStream parses the newline-delimited JSON produced by
+// "backup-stream/": a first line of policy metadata followed by one line
+// per key version. Each line is decoded into the returned Policy's Keys and
+// its raw bytes discarded as it's read from r, so the assembled upload never
+// has to be held as a single in-memory byte slice.
+func decodeBackupStream(r io.Reader) (*keysutil.Policy, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	if !scanner.Scan() {
+		return nil, fmt.Errorf("empty backup stream")
+	}
+
+	var meta struct {
+		Name                    string        `json:"name"`
+		Type                    string        `json:"type"`
+		Derived                 bool          `json:"derived"`
+		ConvergentEncryption    bool          `json:"convergent_encryption"`
+		KDF                     int           `json:"kdf"`
+		Exportable              bool          `json:"exportable"`
+		DeletionAllowed         bool          `json:"deletion_allowed"`
+		MinDecryptionVersion    int           `json:"min_decryption_version"`
+		MinEncryptionVersion    int           `json:"min_encryption_version"`
+		LatestVersion           int           `json:"latest_version"`
+		AutoRotatePeriod        time.Duration `json:"auto_rotate_period"`
+		AutoRotateJitter        time.Duration `json:"auto_rotate_jitter"`
+		MinEncryptionVersionLag int           `json:"min_encryption_version_lag"`
+	}
+	if err := json.Unmarshal(scanner.Bytes(), &meta); err != nil {
+		return nil, fmt.Errorf("failed to decode policy metadata line: %s", err)
+	}
+
+	keyType, err := parseKeyType(meta.Type)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &keysutil.Policy{
+		Name:                    meta.Name,
+		Type:                    keyType,
+		Derived:                 meta.Derived,
+		ConvergentEncryption:    meta.ConvergentEncryption,
+		KDF:                     meta.KDF,
+		Exportable:              meta.Exportable,
+		DeletionAllowed:         meta.DeletionAllowed,
+		MinDecryptionVersion:    meta.MinDecryptionVersion,
+		MinEncryptionVersion:    meta.MinEncryptionVersion,
+		LatestVersion:           meta.LatestVersion,
+		AutoRotatePeriod:        meta.AutoRotatePeriod,
+		AutoRotateJitter:        meta.AutoRotateJitter,
+		MinEncryptionVersionLag: meta.MinEncryptionVersionLag,
+		Keys:                    map[int]keysutil.KeyEntry{},
+	}
+
+	for scanner.Scan() {
+		var versionLine struct {
+			Version int               `json:"version"`
+			Key     keysutil.KeyEntry `json:"key"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &versionLine); err != nil {
+			return nil, fmt.Errorf("failed to decode key version line: %s", err)
+		}
+		p.Keys[versionLine.Version] = versionLine.Key
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+const pathRestoreInitHelpSyn = `Begin a chunked restore upload`
+const pathRestoreInitHelpDesc = `
+This path returns a token identifying a server-side upload buffer. Send
+the backup-stream payload to 'restore-chunk' in base64 frames keyed by
+that token, then call 'restore-commit' to assemble and apply it.
+`
+
+const pathRestoreChunkHelpSyn = `Append a frame to a chunked restore upload`
+const pathRestoreChunkHelpDesc = `
+This path persists a base64 encoded frame of a backup-stream payload as
+its own storage entry under the upload identified by 'token', so no
+single entry ever holds more than one frame's worth of data.
+`
+
+const pathRestoreCommitHelpSyn = `Assemble and apply a chunked restore upload`
+const pathRestoreCommitHelpDesc = `
+This path streams every frame previously sent to 'restore-chunk' for the
+given 'token' back out of storage in order, decodes it incrementally and
+restores the resulting key, then discards the upload's chunks and
+metadata.
+`
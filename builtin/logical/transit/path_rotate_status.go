@@ -0,0 +1,68 @@
+package transit
+
+import (
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+func (b *backend) pathRotateStatus() *framework.Path {
+	return &framework.Path{
+		Pattern: "rotate/status",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathRotateStatusRead,
+		},
+
+		HelpSynopsis:    pathRotateStatusHelpSyn,
+		HelpDescription: pathRotateStatusHelpDesc,
+	}
+}
+
+func (b *backend) pathRotateStatusRead(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	names, err := req.Storage.List("policy/")
+	if err != nil {
+		return nil, err
+	}
+
+	keys := map[string]interface{}{}
+	for _, name := range names {
+		p, lock, err := b.lm.GetPolicyShared(req.Storage, name)
+		if lock != nil {
+			defer lock.RUnlock()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if p == nil || p.AutoRotatePeriod == 0 {
+			continue
+		}
+
+		latest, ok := p.Keys[p.LatestVersion]
+		if !ok {
+			continue
+		}
+
+		keys[name] = map[string]interface{}{
+			"auto_rotate_period":      p.AutoRotatePeriod.String(),
+			"next_scheduled_rotation": latest.CreationTime.Add(p.AutoRotatePeriod).Format(time.RFC3339),
+		}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"keys": keys,
+		},
+	}, nil
+}
+
+const pathRotateStatusHelpSyn = `Report when each auto-rotating key is next due for rotation`
+
+const pathRotateStatusHelpDesc = `
+This path lists every key that has auto_rotate_period configured, along
+with its rotation period and the time its newest version becomes due for
+rotation: that version's creation time plus auto_rotate_period. The
+random auto_rotate_jitter periodicFunc adds on top is not included.
+`
@@ -0,0 +1,110 @@
+package transit
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/hashicorp/vault/logical"
+	"github.com/hashicorp/vault/logical/framework"
+)
+
+const wrappingKeyStoragePath = "wrapping_key"
+
+const wrappingKeyBits = 4096
+
+func (b *backend) pathWrappingKey() *framework.Path {
+	return &framework.Path{
+		Pattern: "wrapping_key",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathWrappingKeyRead,
+		},
+
+		HelpSynopsis:    pathWrappingKeyHelpSyn,
+		HelpDescription: pathWrappingKeyHelpDesc,
+	}
+}
+
+func (b *backend) pathWrappingKeyRead(
+	req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	wrappingKey, err := b.getWrappingKey(req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	derBytes, err := x509.MarshalPKIXPublicKey(&wrappingKey.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal wrapping key: %s", err)
+	}
+	pemKey := pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: derBytes,
+	})
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"public_key": string(pemKey),
+		},
+	}, nil
+}
+
+// getWrappingKey returns the mount's RSA wrapping key used to protect key
+// material sent to the "import/" endpoint, generating and persisting one on
+// first use. The key is cached on the backend so repeated imports don't pay
+// the generation cost or a storage round trip.
+func (b *backend) getWrappingKey(storage logical.Storage) (*rsa.PrivateKey, error) {
+	b.wrappingKeyLock.Lock()
+	defer b.wrappingKeyLock.Unlock()
+
+	if b.wrappingKey != nil {
+		return b.wrappingKey, nil
+	}
+
+	entry, err := storage.Get(wrappingKeyStoragePath)
+	if err != nil {
+		return nil, err
+	}
+	if entry != nil {
+		key, err := x509.ParsePKCS8PrivateKey(entry.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse stored wrapping key: %s", err)
+		}
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("stored wrapping key is not an RSA key")
+		}
+		b.wrappingKey = rsaKey
+		return b.wrappingKey, nil
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, wrappingKeyBits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate wrapping key: %s", err)
+	}
+
+	derBytes, err := x509.MarshalPKCS8PrivateKey(rsaKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal wrapping key: %s", err)
+	}
+
+	if err := storage.Put(&logical.StorageEntry{
+		Key:   wrappingKeyStoragePath,
+		Value: derBytes,
+	}); err != nil {
+		return nil, err
+	}
+
+	b.wrappingKey = rsaKey
+	return b.wrappingKey, nil
+}
+
+const pathWrappingKeyHelpSyn = `Returns the public key to use for wrapping imported keys`
+
+const pathWrappingKeyHelpDesc = `
+This path is used to retrieve the PEM encoded public wrapping key, generated
+per mount on first use, that callers must use to RSA-OAEP wrap the AES key
+protecting key material sent to the 'import/' endpoint.
+`
@@ -0,0 +1,84 @@
+package transit
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+// periodicFunc is wired up as the backend's framework.Backend.PeriodicFunc
+// in Backend(). Vault's RollbackManager only invokes PeriodicFunc on the
+// active node of a cluster, so no extra leader-election bookkeeping is
+// needed here: each mount's keys are rotated by one node only.
+//
+// A single policy's rotation failing doesn't stop the sweep: every other
+// name on the mount still gets its turn, and the failures are reported
+// together once the whole list has been walked.
+func (b *backend) periodicFunc(req *logical.Request) error {
+	names, err := req.Storage.List("policy/")
+	if err != nil {
+		return err
+	}
+
+	var failures []string
+	for _, name := range names {
+		if err := b.rotateIfDue(req.Storage, name); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", name, err))
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("failed to auto-rotate %d key(s): %s", len(failures), strings.Join(failures, "; "))
+	}
+
+	return nil
+}
+
+func (b *backend) rotateIfDue(storage logical.Storage, name string) error {
+	p, lock, err := b.lm.GetPolicyShared(storage, name)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return nil
+	}
+
+	autoRotatePeriod := p.AutoRotatePeriod
+	autoRotateJitter := p.AutoRotateJitter
+	minEncryptionVersionLag := p.MinEncryptionVersionLag
+
+	var lastCreated time.Time
+	if latest, ok := p.Keys[p.LatestVersion]; ok {
+		lastCreated = latest.CreationTime
+	}
+
+	if lock != nil {
+		lock.RUnlock()
+	}
+
+	if autoRotatePeriod == 0 || lastCreated.IsZero() {
+		return nil
+	}
+
+	due := lastCreated.Add(autoRotatePeriod)
+	if autoRotateJitter > 0 {
+		due = due.Add(time.Duration(rand.Int63n(int64(autoRotateJitter))))
+	}
+	if time.Now().Before(due) {
+		return nil
+	}
+
+	newVersion, err := b.lm.RotatePolicy(storage, name)
+	if err != nil {
+		return err
+	}
+
+	if minEncryptionVersionLag > 0 && newVersion > minEncryptionVersionLag {
+		return b.lm.SetMinEncryptionVersion(storage, name, newVersion-minEncryptionVersionLag)
+	}
+
+	return nil
+}
@@ -0,0 +1,16 @@
+package keysutil
+
+// KeyData is the envelope used by the "backup/" and "restore/" endpoints. It
+// wraps a full Policy so that every key version can be round-tripped as one
+// unit. The backup and restore paths fill and read only Policy;
+// ArchivedKeys is left nil on backup and ignored on restore.
+type KeyData struct {
+	Policy       *Policy       `json:"policy"`
+	ArchivedKeys *archivedKeys `json:"archived_keys"`
+}
+
+// archivedKeys holds key versions that have aged out of a Policy's live Keys
+// map but are still needed to decrypt old ciphertexts.
+type archivedKeys struct {
+	Keys []KeyEntry `json:"keys"`
+}
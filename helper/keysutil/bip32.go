@@ -0,0 +1,135 @@
+package keysutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const bip32HardenedOffset = 0x80000000
+
+var bip32CurveOrder = btcec.S256().N
+
+// DeriveBIP39Seed turns a BIP39 mnemonic (plus an optional passphrase) into
+// the 64-byte seed used to generate a BIP32 master key: PBKDF2-HMAC-SHA512
+// over the mnemonic, salted with "mnemonic" + passphrase, 2048 rounds, per
+// the BIP39 spec.
+func DeriveBIP39Seed(mnemonic, passphrase string) []byte {
+	return pbkdf2.Key([]byte(mnemonic), []byte("mnemonic"+passphrase), 2048, 64, sha512.New)
+}
+
+// MasterKeyFromSeed generates a BIP32 master private key and chain code
+// from a seed, via HMAC-SHA512 keyed with the fixed string "Bitcoin seed".
+func MasterKeyFromSeed(seed []byte) (key, chainCode []byte, err error) {
+	mac := hmac.New(sha512.New, []byte("Bitcoin seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	key = sum[:32]
+	chainCode = sum[32:]
+
+	if new(big.Int).SetBytes(key).Cmp(bip32CurveOrder) >= 0 {
+		return nil, nil, errors.New("invalid seed: derived master key is not a valid secp256k1 scalar")
+	}
+	return key, chainCode, nil
+}
+
+// ParseDerivationPath parses a BIP32 path such as "m/44'/60'/0'/0/0" into a
+// list of child indexes, setting the hardened-derivation bit for any
+// component suffixed with "'" or "h".
+func ParseDerivationPath(path string) ([]uint32, error) {
+	parts := strings.Split(path, "/")
+	if len(parts) == 0 || parts[0] != "m" {
+		return nil, errors.New(`derivation path must start with "m"`)
+	}
+
+	indexes := make([]uint32, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		hardened := strings.HasSuffix(part, "'") || strings.HasSuffix(part, "h")
+		if hardened {
+			part = strings.TrimSuffix(strings.TrimSuffix(part, "'"), "h")
+		}
+
+		index, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid derivation path component %q: %s", part, err)
+		}
+		if hardened {
+			index += bip32HardenedOffset
+		}
+		indexes = append(indexes, uint32(index))
+	}
+
+	return indexes, nil
+}
+
+// DeriveChildKey performs BIP32 CKDpriv: deriving the private key and chain
+// code for a single child index from a parent private key and chain code.
+func DeriveChildKey(parentKey, parentChainCode []byte, index uint32) (childKey, childChainCode []byte, err error) {
+	var data []byte
+	if index >= bip32HardenedOffset {
+		data = append([]byte{0x00}, parentKey...)
+	} else {
+		_, pub := btcec.PrivKeyFromBytes(btcec.S256(), parentKey)
+		data = pub.SerializeCompressed()
+	}
+
+	idxBytes := make([]byte, 4)
+	binary.BigEndian.PutUint32(idxBytes, index)
+	data = append(data, idxBytes...)
+
+	mac := hmac.New(sha512.New, parentChainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	il := new(big.Int).SetBytes(sum[:32])
+	if il.Cmp(bip32CurveOrder) >= 0 {
+		return nil, nil, errors.New("invalid derivation: IL is not a valid secp256k1 scalar")
+	}
+
+	childScalar := new(big.Int).Add(il, new(big.Int).SetBytes(parentKey))
+	childScalar.Mod(childScalar, bip32CurveOrder)
+	if childScalar.Sign() == 0 {
+		return nil, nil, errors.New("invalid derivation: resulting child key is zero")
+	}
+
+	childKey = make([]byte, 32)
+	childScalar.FillBytes(childKey)
+
+	return childKey, sum[32:], nil
+}
+
+// DeriveChildKeyPath walks every index in path starting from (key,
+// chainCode), returning the key and chain code at the end of the path.
+func DeriveChildKeyPath(key, chainCode []byte, path []uint32) (childKey, childChainCode []byte, err error) {
+	for _, index := range path {
+		key, chainCode, err = DeriveChildKey(key, chainCode, index)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return key, chainCode, nil
+}
+
+// Secp256k1PrivateKey wraps a raw 32-byte secp256k1 scalar as an
+// *ecdsa.PrivateKey so it can be handed to APIs that expect one.
+func Secp256k1PrivateKey(key []byte) *ecdsa.PrivateKey {
+	priv, pub := btcec.PrivKeyFromBytes(btcec.S256(), key)
+	return &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: btcec.S256(),
+			X:     pub.X,
+			Y:     pub.Y,
+		},
+		D: priv.D,
+	}
+}
@@ -0,0 +1,85 @@
+package keysutil
+
+import (
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+)
+
+// JSONWebKey is a minimal RFC 7517 JSON Web Key, carrying only the fields
+// transit's asymmetric key types need. It's deliberately hand-rolled rather
+// than pulled from a JOSE/JWT dependency: the shape is small and fixed, and
+// only public key material is ever put into one.
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// EC and OKP (Ed25519)
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// ToJWK renders the public half of the given key version as a JSONWebKey,
+// with kid set to the version number so JWKS consumers can match a JWK back
+// to the transit key version that produced it.
+func (p *Policy) ToJWK(version int, key *KeyEntry) (*JSONWebKey, error) {
+	jwk := &JSONWebKey{
+		Kid: fmt.Sprintf("%d", version),
+	}
+
+	if p.Type.SigningSupported() {
+		jwk.Use = "sig"
+	} else if p.Type.EncryptionSupported() {
+		jwk.Use = "enc"
+	}
+
+	switch p.Type {
+	case KeyType_RSA2048, KeyType_RSA4096:
+		if key.RSAKey == nil {
+			return nil, fmt.Errorf("key version %d has no RSA key material", version)
+		}
+		jwk.Kty = "RSA"
+		jwk.Alg = "RS256"
+		jwk.N = base64URL(key.RSAKey.PublicKey.N.Bytes())
+		jwk.E = base64URL(big.NewInt(int64(key.RSAKey.PublicKey.E)).Bytes())
+
+	case KeyType_ECDSA_P256:
+		ecKey, err := KeyEntryToECDSAPrivateKey(key, elliptic.P256())
+		if err != nil {
+			return nil, err
+		}
+		jwk.Kty = "EC"
+		jwk.Alg = "ES256"
+		jwk.Crv = "P-256"
+		jwk.X = base64URL(ecKey.X.Bytes())
+		jwk.Y = base64URL(ecKey.Y.Bytes())
+
+	case KeyType_ED25519:
+		pub, ok := ed25519.PrivateKey(key.Key).Public().(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("key version %d has no ed25519 key material", version)
+		}
+		jwk.Kty = "OKP"
+		jwk.Alg = "EdDSA"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64URL(pub)
+
+	default:
+		return nil, fmt.Errorf("key type %s does not support JWK export", p.Type)
+	}
+
+	return jwk, nil
+}
+
+func base64URL(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,212 @@
+package keysutil
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+// LockManager caches unsealed Policies in memory and serializes access to
+// the backing storage entries that persist them. Every named key gets its
+// own RWMutex so that concurrent requests against unrelated keys never
+// block one another.
+type LockManager struct {
+	policies map[string]*Policy
+	locks    map[string]*sync.RWMutex
+	mapLock  sync.RWMutex
+}
+
+func NewLockManager() *LockManager {
+	return &LockManager{
+		policies: make(map[string]*Policy),
+		locks:    make(map[string]*sync.RWMutex),
+	}
+}
+
+func policyStoragePath(name string) string {
+	return "policy/" + name
+}
+
+func (lm *LockManager) lockFor(name string) *sync.RWMutex {
+	lm.mapLock.Lock()
+	defer lm.mapLock.Unlock()
+	lock, ok := lm.locks[name]
+	if !ok {
+		lock = &sync.RWMutex{}
+		lm.locks[name] = lock
+	}
+	return lock
+}
+
+// GetPolicyShared returns the named policy read-locked: callers must RUnlock
+// the returned lock once they're done with the policy. A nil policy with a
+// nil lock and nil error means the key doesn't exist.
+func (lm *LockManager) GetPolicyShared(storage logical.Storage, name string) (*Policy, *sync.RWMutex, error) {
+	lock := lm.lockFor(name)
+	lock.RLock()
+
+	lm.mapLock.RLock()
+	p, ok := lm.policies[name]
+	lm.mapLock.RUnlock()
+	if ok {
+		return p, lock, nil
+	}
+
+	p, err := lm.loadPolicy(storage, name)
+	if err != nil {
+		lock.RUnlock()
+		return nil, nil, err
+	}
+	if p == nil {
+		lock.RUnlock()
+		return nil, nil, nil
+	}
+
+	lm.mapLock.Lock()
+	lm.policies[name] = p
+	lm.mapLock.Unlock()
+
+	return p, lock, nil
+}
+
+func (lm *LockManager) loadPolicy(storage logical.Storage, name string) (*Policy, error) {
+	entry, err := storage.Get(policyStoragePath(name))
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	p := &Policy{}
+	if err := entry.DecodeJSON(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (lm *LockManager) storePolicy(storage logical.Storage, p *Policy) error {
+	if err := p.Persist(storage); err != nil {
+		return err
+	}
+
+	lm.mapLock.Lock()
+	lm.policies[p.Name] = p
+	lm.mapLock.Unlock()
+	return nil
+}
+
+// RotatePolicy write-locks the named policy, generates a new key version for
+// it and persists the result, returning the new version number.
+func (lm *LockManager) RotatePolicy(storage logical.Storage, name string) (int, error) {
+	lock := lm.lockFor(name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	p, err := lm.loadPolicy(storage, name)
+	if err != nil {
+		return 0, err
+	}
+	if p == nil {
+		return 0, fmt.Errorf("no such key %q", name)
+	}
+
+	version, err := p.Rotate(storage)
+	if err != nil {
+		return 0, err
+	}
+
+	lm.mapLock.Lock()
+	lm.policies[name] = p
+	lm.mapLock.Unlock()
+
+	return version, nil
+}
+
+// PersistExisting write-locks the named policy and persists the caller's
+// in-memory copy of it, e.g. after updating configuration fields such as
+// AutoRotatePeriod that don't require re-deriving key material.
+func (lm *LockManager) PersistExisting(storage logical.Storage, name string, p *Policy) error {
+	lock := lm.lockFor(name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	return lm.storePolicy(storage, p)
+}
+
+// SetMinEncryptionVersion write-locks the named policy and updates its
+// MinEncryptionVersion, e.g. to retire older versions a configured number of
+// rotations after they were last the latest.
+func (lm *LockManager) SetMinEncryptionVersion(storage logical.Storage, name string, version int) error {
+	lock := lm.lockFor(name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	p, err := lm.loadPolicy(storage, name)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return fmt.Errorf("no such key %q", name)
+	}
+
+	p.MinEncryptionVersion = version
+	return lm.storePolicy(storage, p)
+}
+
+// SetAutoRotateConfig write-locks the named policy and updates its automatic
+// rotation settings, e.g. via the "config/auto_rotate" endpoint.
+func (lm *LockManager) SetAutoRotateConfig(storage logical.Storage, name string, period, jitter time.Duration, minEncryptionVersionLag int) error {
+	lock := lm.lockFor(name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	p, err := lm.loadPolicy(storage, name)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return fmt.Errorf("no such key %q", name)
+	}
+
+	p.AutoRotatePeriod = period
+	p.AutoRotateJitter = jitter
+	p.MinEncryptionVersionLag = minEncryptionVersionLag
+	return lm.storePolicy(storage, p)
+}
+
+// ImportPolicy persists a fully-formed Policy built from externally supplied
+// key material. It mirrors RestorePolicy but takes a policy directly rather
+// than a backup blob, and, like the "import/" endpoint it backs, refuses to
+// overwrite an existing key by that name.
+func (lm *LockManager) ImportPolicy(storage logical.Storage, name string, p *Policy) error {
+	lock := lm.lockFor(name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	existing, err := lm.loadPolicy(storage, name)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("key %q already exists", name)
+	}
+
+	p.Name = name
+	return lm.storePolicy(storage, p)
+}
+
+// RestorePolicy persists a Policy recovered from a "backup/" payload.
+func (lm *LockManager) RestorePolicy(storage logical.Storage, backup KeyData) error {
+	if backup.Policy == nil {
+		return fmt.Errorf("no policy found in backup data")
+	}
+
+	lock := lm.lockFor(backup.Policy.Name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	return lm.storePolicy(storage, backup.Policy)
+}
@@ -0,0 +1,315 @@
+package keysutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/hashicorp/vault/logical"
+)
+
+// KeyType represents the underlying cryptographic algorithm (and mode, where
+// relevant) used by a Policy.
+type KeyType int
+
+const (
+	KeyType_AES256_GCM96 = iota
+	KeyType_ECDSA_P256
+	KeyType_ED25519
+	KeyType_RSA2048
+	KeyType_RSA4096
+	KeyType_BIP32_SECP256K1
+)
+
+func (kt KeyType) EncryptionSupported() bool {
+	switch kt {
+	case KeyType_AES256_GCM96, KeyType_RSA2048, KeyType_RSA4096:
+		return true
+	}
+	return false
+}
+
+func (kt KeyType) SigningSupported() bool {
+	switch kt {
+	case KeyType_ECDSA_P256, KeyType_ED25519, KeyType_RSA2048, KeyType_RSA4096, KeyType_BIP32_SECP256K1:
+		return true
+	}
+	return false
+}
+
+func (kt KeyType) HMACSupported() bool {
+	switch kt {
+	case KeyType_AES256_GCM96:
+		return true
+	}
+	return false
+}
+
+// RotationSupported reports whether generateKeyEntry knows how to create a
+// new key version for this key type, i.e. whether p.Rotate can succeed. A
+// bip32-secp256k1 policy's key versions are deterministically derived from
+// one fixed master key and chain code, so there's no "new version" to
+// generate; Rotate would just error.
+func (kt KeyType) RotationSupported() bool {
+	return kt != KeyType_BIP32_SECP256K1
+}
+
+func (kt KeyType) String() string {
+	switch kt {
+	case KeyType_AES256_GCM96:
+		return "aes256-gcm96"
+	case KeyType_ECDSA_P256:
+		return "ecdsa-p256"
+	case KeyType_ED25519:
+		return "ed25519"
+	case KeyType_RSA2048:
+		return "rsa-2048"
+	case KeyType_RSA4096:
+		return "rsa-4096"
+	case KeyType_BIP32_SECP256K1:
+		return "bip32-secp256k1"
+	}
+	return "[unknown]"
+}
+
+// KeyEntry stores the key material and metadata for a single version of a
+// Policy.
+type KeyEntry struct {
+	Key          []byte    `json:"key"`
+	HMACKey      []byte    `json:"hmac_key"`
+	CreationTime time.Time `json:"creation_time"`
+
+	RSAKey *rsa.PrivateKey `json:"rsa_key"`
+
+	// EC_X, EC_Y and EC_D hold the public and private components of an
+	// ECDSA key. They are stored as big.Int rather than as an
+	// *ecdsa.PrivateKey so that the entry round-trips cleanly through JSON.
+	EC_X *big.Int `json:"ec_x"`
+	EC_Y *big.Int `json:"ec_y"`
+	EC_D *big.Int `json:"ec_d"`
+
+	// ChainCode holds the BIP32 chain code paired with Key for a
+	// KeyType_BIP32_SECP256K1 entry, used to derive child keys via CKDpriv.
+	ChainCode []byte `json:"chain_code"`
+
+	// FormattedPublicKey holds a PEM encoded public key for asymmetric key
+	// types. Neither generateKeyEntry nor the import path fills it in, so it
+	// is empty on entries created by this package.
+	FormattedPublicKey string `json:"formatted_public_key"`
+}
+
+// Policy represents a named key and every version of key material that has
+// ever been generated for it.
+type Policy struct {
+	Name string  `json:"name"`
+	Type KeyType `json:"type"`
+
+	Keys map[int]KeyEntry `json:"keys"`
+
+	Derived              bool `json:"derived"`
+	KDF                  int  `json:"kdf"`
+	ConvergentEncryption bool `json:"convergent_encryption"`
+
+	Exportable      bool `json:"exportable"`
+	DeletionAllowed bool `json:"deletion_allowed"`
+
+	MinDecryptionVersion int `json:"min_decryption_version"`
+	MinEncryptionVersion int `json:"min_encryption_version"`
+	LatestVersion        int `json:"latest_version"`
+	ArchiveVersion       int `json:"archive_version"`
+
+	StoragePrefix string `json:"storage_prefix"`
+
+	// AutoRotatePeriod is how long a key version may exist before
+	// periodicFunc rotates it. Zero disables automatic rotation.
+	AutoRotatePeriod time.Duration `json:"auto_rotate_period"`
+
+	// AutoRotateJitter adds up to this much random slack to
+	// AutoRotatePeriod so that keys created around the same time don't all
+	// rotate in the same periodicFunc tick.
+	AutoRotateJitter time.Duration `json:"auto_rotate_jitter"`
+
+	// MinEncryptionVersionLag, when non-zero, is applied after every
+	// automatic rotation: MinEncryptionVersion is set to
+	// LatestVersion - MinEncryptionVersionLag, so callers are forced onto
+	// newer key versions within a bounded number of rotations.
+	MinEncryptionVersionLag int `json:"min_encryption_version_lag"`
+}
+
+// Map returns the policy as a plain map, suitable for returning from the
+// "export/all" path or for serializing into a backup. When includeKeys is
+// false, key material is omitted and only metadata is returned.
+func (p *Policy) Map(req interface{}, includeKeys bool) (map[string]interface{}, error) {
+	data := map[string]interface{}{
+		"name":                       p.Name,
+		"type":                       p.Type.String(),
+		"derived":                    p.Derived,
+		"kdf":                        p.KDF,
+		"convergent_encryption":      p.ConvergentEncryption,
+		"exportable":                 p.Exportable,
+		"deletion_allowed":           p.DeletionAllowed,
+		"min_decryption_version":     p.MinDecryptionVersion,
+		"min_encryption_version":     p.MinEncryptionVersion,
+		"latest_version":             p.LatestVersion,
+		"auto_rotate_period":         p.AutoRotatePeriod,
+		"auto_rotate_jitter":         p.AutoRotateJitter,
+		"min_encryption_version_lag": p.MinEncryptionVersionLag,
+	}
+
+	if includeKeys {
+		data["keys"] = p.Keys
+	}
+
+	return data, nil
+}
+
+// EncodeRSAPrivateKey renders an RSA private key using Vault's legacy
+// human-readable PEM-ish container. Kept for backwards compatibility with
+// clients that parse the "" (default) export format.
+func EncodeRSAPrivateKey(key *rsa.PrivateKey) string {
+	if key == nil {
+		return ""
+	}
+	pemBlock := &pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(key),
+	}
+	return string(pem.EncodeToMemory(pemBlock))
+}
+
+// KeyEntryToECPrivateKey renders the EC key material in a KeyEntry as a PEM
+// encoded EC PRIVATE KEY block. Kept for backwards compatibility with the
+// legacy "" export format.
+func KeyEntryToECPrivateKey(k *KeyEntry, curve elliptic.Curve) (string, error) {
+	priv, err := KeyEntryToECDSAPrivateKey(k, curve)
+	if err != nil {
+		return "", err
+	}
+	derBytes, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		return "", err
+	}
+	pemBlock := &pem.Block{
+		Type:  "EC PRIVATE KEY",
+		Bytes: derBytes,
+	}
+	return string(pem.EncodeToMemory(pemBlock)), nil
+}
+
+// KeyEntryToECDSAPrivateKey reconstructs a usable *ecdsa.PrivateKey from the
+// big.Int components stored on the KeyEntry.
+func KeyEntryToECDSAPrivateKey(k *KeyEntry, curve elliptic.Curve) (*ecdsa.PrivateKey, error) {
+	if k == nil {
+		return nil, fmt.Errorf("nil key entry provided")
+	}
+	if k.EC_D == nil || k.EC_X == nil || k.EC_Y == nil {
+		return nil, fmt.Errorf("missing EC key material in key entry")
+	}
+
+	return &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: curve,
+			X:     k.EC_X,
+			Y:     k.EC_Y,
+		},
+		D: k.EC_D,
+	}, nil
+}
+
+// Persist writes the policy to storage under its well-known path. Callers
+// that hold a policy obtained through a LockManager should generally go
+// through LockManager.PersistExisting instead, so the manager's in-memory
+// cache stays in sync.
+func (p *Policy) Persist(storage logical.Storage) error {
+	entry, err := logical.StorageEntryJSON(policyStoragePath(p.Name), p)
+	if err != nil {
+		return err
+	}
+	return storage.Put(entry)
+}
+
+// Rotate generates a new key version using the same key generation logic as
+// initial key creation, appends it as the new LatestVersion, and persists
+// the updated policy.
+func (p *Policy) Rotate(storage logical.Storage) (int, error) {
+	entry, err := generateKeyEntry(p.Type)
+	if err != nil {
+		return 0, err
+	}
+
+	if p.Keys == nil {
+		p.Keys = make(map[int]KeyEntry)
+	}
+
+	p.LatestVersion++
+	p.Keys[p.LatestVersion] = entry
+
+	if err := p.Persist(storage); err != nil {
+		return 0, err
+	}
+
+	return p.LatestVersion, nil
+}
+
+// generateKeyEntry creates fresh key material appropriate for keyType,
+// stamped with the current time so rotation scheduling has something to
+// measure against.
+func generateKeyEntry(keyType KeyType) (KeyEntry, error) {
+	entry := KeyEntry{
+		CreationTime: time.Now(),
+	}
+
+	switch keyType {
+	case KeyType_AES256_GCM96:
+		entry.Key = make([]byte, 32)
+		if _, err := rand.Read(entry.Key); err != nil {
+			return entry, err
+		}
+		entry.HMACKey = make([]byte, 32)
+		if _, err := rand.Read(entry.HMACKey); err != nil {
+			return entry, err
+		}
+
+	case KeyType_ECDSA_P256:
+		priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return entry, err
+		}
+		entry.EC_X = priv.X
+		entry.EC_Y = priv.Y
+		entry.EC_D = priv.D
+
+	case KeyType_ED25519:
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return entry, err
+		}
+		entry.Key = priv
+
+	case KeyType_RSA2048:
+		priv, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return entry, err
+		}
+		entry.RSAKey = priv
+
+	case KeyType_RSA4096:
+		priv, err := rsa.GenerateKey(rand.Reader, 4096)
+		if err != nil {
+			return entry, err
+		}
+		entry.RSAKey = priv
+
+	default:
+		return entry, fmt.Errorf("unsupported key type %v", keyType)
+	}
+
+	return entry, nil
+}